@@ -0,0 +1,68 @@
+// Package cmd assembles the `stripe` command tree and is the CLI's single
+// entrypoint, so this is where invocation-scoped concerns like telemetry
+// get wired in once rather than duplicated per command.
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/stripe/metrics"
+)
+
+// rootCmd is the parent of every `stripe` subcommand.
+var rootCmd = &cobra.Command{
+	Use:   "stripe",
+	Short: "A command-line tool for Stripe",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		eventCtx := stripe.EventMetadataFromContext(cmd.Context())
+		eventCtx.SetCommandContext(cmd)
+		eventCtx.SetCommandFlags(cmd)
+		eventCtx.SetInvocationID()
+		eventCtx.SetRequestID(cmd.Context())
+
+		metrics.CommandsExecuted.WithLabelValues(cmd.CommandPath()).Inc()
+
+		return nil
+	},
+}
+
+// metricsCmd groups commands for inspecting the CLI's own Prometheus
+// metrics. `serve`, registered below, is hidden since most users will
+// never need it.
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Inspect metrics emitted by the CLI",
+}
+
+func init() {
+	metricsCmd.AddCommand(metrics.NewServeCmd())
+	rootCmd.AddCommand(metricsCmd)
+}
+
+// Execute runs the root command for the current process. It wraps the
+// process exit so every invocation reports a CommandExited telemetry event
+// carrying its real exit code, instead of letting the queued telemetry
+// started below get dropped when the process exits. This is also the only
+// place that shuts the telemetry queue down: cobra runs PersistentPostRun
+// on every successful command before ExecuteContext returns, so shutting
+// down there too would close the queue before the CommandExited event is
+// enqueued here, and enqueueing on the closed queue panics.
+func Execute() {
+	ctx := stripe.WithRequestID(context.Background(), stripe.NewRequestID())
+	ctx = stripe.WithTelemetryClient(ctx, stripe.NewStripeTelemetryClient())
+	ctx = stripe.WithEventMetadata(ctx, stripe.GetAnalyticsEventContext())
+	ctx = stripe.StartTelemetry(ctx)
+
+	err := rootCmd.ExecuteContext(ctx)
+
+	code := 0
+	if err != nil {
+		code = 1
+		metrics.CommandsFailed.WithLabelValues(stripe.EventMetadataFromContext(ctx).CommandPath).Inc()
+	}
+
+	stripe.ExitWithTelemetry(ctx, code)
+}
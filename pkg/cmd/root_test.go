@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/stripe"
+)
+
+// TestRootCommandDoesNotShutdownTelemetryTwice guards against rootCmd
+// shutting the telemetry queue down in PersistentPostRun as well as in
+// Execute's ExitWithTelemetry. cobra runs PersistentPostRun on every
+// successful command, inside ExecuteContext, so the queue would already be
+// closed when ExitWithTelemetry enqueues CommandExited on it, and that send
+// on the closed channel panics. This is the common path, not an edge case.
+func TestRootCommandDoesNotShutdownTelemetryTwice(t *testing.T) {
+	if rootCmd.PersistentPostRun != nil || rootCmd.PersistentPostRunE != nil {
+		t.Fatal("rootCmd must not shut the telemetry queue down in PersistentPostRun; Execute's ExitWithTelemetry is the only shutdown path")
+	}
+
+	noop := &cobra.Command{
+		Use: "noop",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+	rootCmd.AddCommand(noop)
+	defer rootCmd.RemoveCommand(noop)
+
+	ctx := stripe.WithTelemetryClient(context.Background(), stripe.NoopTelemetryClient{})
+	ctx = stripe.WithEventMetadata(ctx, stripe.GetAnalyticsEventContext())
+	ctx = stripe.StartTelemetry(ctx)
+
+	rootCmd.SetArgs([]string{"noop"})
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("running a successful command panicked: %v", r)
+		}
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Shutting down once here mirrors the single shutdown Execute performs
+	// via ExitWithTelemetry after rootCmd.ExecuteContext returns.
+	stripe.ShutdownTelemetry(ctx)
+}
@@ -2,26 +2,26 @@ package stripe
 
 import (
 	"context"
-	"fmt"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/go-querystring/query"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 //
 // Public types
 //
 
-// CLIAnalyticsEventContext is the structure that holds telemetry data sent to the Stripe Analytics Service
-// this could be a problem if we are running multiple commands at once. I think we want to initialize this once and pass it along.
+// CLIAnalyticsEventContext is the structure that holds telemetry data sent to the Stripe Analytics Service.
+// Rather than reaching for the process-wide instance below, prefer attaching one to a context with
+// WithEventMetadata and reading it back with EventMetadataFromContext so concurrent commands don't race
+// on the same fields.
 type CLIAnalyticsEventContext struct {
 	UserAgent         string `url:"user_agent"`
 	InvocationID      string `url:"invocation_id"`
@@ -32,10 +32,9 @@ type CLIAnalyticsEventContext struct {
 	GeneratedResource bool   `url:"generated_resource"`
 	RequestID         string `url:"request_id"`
 	LiveMode          bool   `url:"livemode"`
+	CommandFlags      string `url:"command_flags"`
 }
 
-// Add a public interface for the sendEvent
-
 //
 // Public functions
 //
@@ -80,48 +79,25 @@ func (e *CLIAnalyticsEventContext) SetInvocationID() {
 	e.InvocationID = uuid.NewString()
 }
 
-// SendEvent sends a telemetry event to r.stripe.com
-func (e *CLIAnalyticsEventContext) SendEvent(ctx context.Context, eventName string, eventValue string) (*http.Response, error) {
-	time.Sleep(5 * time.Second)
-	client := newTelemetryHTTPClient(false)
-
-	if telemetryOptedOut(os.Getenv("STRIPE_CLI_TELEMETRY_OPTOUT")) {
-		return nil, nil
-	}
-
-	analyticsURL, err := url.Parse("https://r.stripe.com/0")
-	if err != nil {
-		return nil, err
-	}
-
-	data, _ := query.Values(e)
-
-	data.Set("client_id", "stripe-cli")
-	data.Set("event_id", uuid.NewString())
-	data.Set("event_name", eventName)
-	data.Set("event_value", eventValue)
-	data.Set("created", fmt.Sprint((time.Now().Unix())))
-
-	req, err := http.NewRequest(http.MethodPost, analyticsURL.String(), strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("origin", "stripe-cli")
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	if ctx != nil {
-		req = req.WithContext(ctx)
-	}
+// SetRequestID sets RequestID to the correlation ID attached to ctx (see
+// WithRequestID), so the event can be matched up with the CLI's own logs
+// and with the Stripe backend request(s) the invocation made.
+func (e *CLIAnalyticsEventContext) SetRequestID(ctx context.Context) {
+	e.RequestID = RequestIDFromContext(ctx)
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+// SetCommandFlags records the names (never the values) of the flags the
+// user actually set on cmd, joined by commas. This gives us visibility into
+// which options users reach for without ever seeing anything they might
+// have passed as a flag value, like an API key.
+func (e *CLIAnalyticsEventContext) SetCommandFlags(cmd *cobra.Command) {
+	var flags []string
 
-	fmt.Printf("Sent telemetry event")
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		flags = append(flags, flag.Name)
+	})
 
-	return resp, nil
+	e.CommandFlags = strings.Join(flags, ",")
 }
 
 func newTelemetryHTTPClient(verbose bool) *http.Client {
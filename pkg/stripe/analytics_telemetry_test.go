@@ -0,0 +1,42 @@
+package stripe
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestSetCommandFlagsOnlyCapturesFlagsTheUserSet(t *testing.T) {
+	cmd := &cobra.Command{Use: "listen"}
+	cmd.Flags().String("forward-to", "", "")
+	cmd.Flags().Bool("skip-verify", false, "")
+	cmd.Flags().String("untouched", "default", "")
+
+	if err := cmd.Flags().Set("forward-to", "localhost:3000"); err != nil {
+		t.Fatalf("failed to set forward-to: %v", err)
+	}
+
+	if err := cmd.Flags().Set("skip-verify", "true"); err != nil {
+		t.Fatalf("failed to set skip-verify: %v", err)
+	}
+
+	eventCtx := &CLIAnalyticsEventContext{}
+	eventCtx.SetCommandFlags(cmd)
+
+	want := "forward-to,skip-verify"
+	if eventCtx.CommandFlags != want {
+		t.Fatalf("CommandFlags = %q, want %q (untouched should not appear)", eventCtx.CommandFlags, want)
+	}
+}
+
+func TestSetCommandFlagsWithNoFlagsSet(t *testing.T) {
+	cmd := &cobra.Command{Use: "listen"}
+	cmd.Flags().String("forward-to", "", "")
+
+	eventCtx := &CLIAnalyticsEventContext{}
+	eventCtx.SetCommandFlags(cmd)
+
+	if eventCtx.CommandFlags != "" {
+		t.Fatalf("CommandFlags = %q, want empty string", eventCtx.CommandFlags)
+	}
+}
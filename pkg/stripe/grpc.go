@@ -0,0 +1,35 @@
+package stripe
+
+import (
+	"google.golang.org/grpc"
+)
+
+//
+// Public functions
+//
+
+// NewGRPCClientConn dials target with the CLI's standard dial options,
+// chaining UnaryClientInterceptor and StreamClientInterceptor onto opts so
+// every call this connection makes carries the invocation's request ID in
+// its outgoing metadata.
+func NewGRPCClientConn(target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append(opts,
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor),
+	)
+
+	return grpc.Dial(target, opts...)
+}
+
+// NewGRPCServer returns a *grpc.Server with UnaryServerInterceptor and
+// StreamServerInterceptor chained onto opts, so every handler sees a
+// context carrying the caller's request ID (or a freshly generated one)
+// and a logger that tags its output with it.
+func NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor),
+	)
+
+	return grpc.NewServer(opts...)
+}
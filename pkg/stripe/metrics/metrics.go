@@ -0,0 +1,85 @@
+// Package metrics registers the Prometheus collectors the CLI exposes when
+// a user opts into local scraping (e.g. `stripe metrics serve`), and is
+// also where the telemetry client reports the same counters it sends
+// upstream, so a single instrumentation point covers both external
+// reporting and local Prometheus scraping.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry is the Prometheus registry used by the CLI's /metrics endpoint.
+// It's a package-level var rather than a singleton accessor because, unlike
+// the telemetry client, there's only ever one local scrape target per
+// process.
+var Registry = prometheus.NewRegistry()
+
+// Counters and histograms shared across the telemetry client and any
+// command that wants to record CLI-specific metrics.
+var (
+	// CommandsExecuted counts every command invocation, labeled by command
+	// path (e.g. "stripe listen").
+	CommandsExecuted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stripe_cli_commands_executed_total",
+		Help: "Total number of CLI commands executed, labeled by command path.",
+	}, []string{"command"})
+
+	// CommandsFailed counts command invocations that returned a non-zero
+	// exit code, labeled by command path.
+	CommandsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stripe_cli_commands_failed_total",
+		Help: "Total number of CLI commands that exited with an error, labeled by command path.",
+	}, []string{"command"})
+
+	// TelemetryEventsSent counts telemetry events successfully flushed to a
+	// TelemetryClient backend.
+	TelemetryEventsSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stripe_cli_telemetry_events_sent_total",
+		Help: "Total number of telemetry events successfully sent.",
+	})
+
+	// TelemetryEventsDropped counts telemetry events dropped because the
+	// queue was full or a flush exhausted its retries.
+	TelemetryEventsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stripe_cli_telemetry_events_dropped_total",
+		Help: "Total number of telemetry events dropped without being sent.",
+	})
+
+	// WebhookEventsForwarded counts events the CLI has forwarded from
+	// Stripe to a local webhook endpoint, labeled by event type.
+	//
+	// Not yet incremented anywhere: this tree doesn't have the webhook
+	// forwarding subsystem that would call it. Whoever adds it should
+	// increment this alongside the forward, not skip wiring it in.
+	WebhookEventsForwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stripe_cli_webhook_events_forwarded_total",
+		Help: "Total number of webhook events forwarded to a local endpoint, labeled by event type.",
+	}, []string{"event_type"})
+
+	// APIRequestDuration observes the latency of outgoing Stripe API
+	// requests, labeled by method and path.
+	//
+	// Not yet observed anywhere: this tree doesn't have the Stripe API
+	// HTTP client that would record it. Whoever adds it should observe
+	// this around the request round trip, not skip wiring it in.
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stripe_cli_api_request_duration_seconds",
+		Help:    "Duration of outgoing Stripe API requests in seconds, labeled by method and path.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		CommandsExecuted,
+		CommandsFailed,
+		TelemetryEventsSent,
+		TelemetryEventsDropped,
+		WebhookEventsForwarded,
+		APIRequestDuration,
+	)
+}
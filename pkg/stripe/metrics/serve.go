@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+// NewServeCmd returns the hidden `stripe metrics serve` command, which
+// exposes Registry on /metrics for scraping by a local Prometheus while a
+// long-running command like `stripe listen` is active. It's registered as
+// a hidden subcommand of `stripe metrics` from the root command, since most
+// users will never need it.
+func NewServeCmd() *cobra.Command {
+	var listenAddr string
+
+	cmd := &cobra.Command{
+		Use:    "serve",
+		Hidden: true,
+		Short:  "Serve Prometheus metrics for the running CLI process",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(listenAddr)
+		},
+	}
+
+	cmd.Flags().StringVar(&listenAddr, "listen", ":9219", "address to serve /metrics on")
+
+	return cmd
+}
+
+func serve(listenAddr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+	return http.ListenAndServe(listenAddr, mux)
+}
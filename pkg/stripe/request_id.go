@@ -0,0 +1,148 @@
+package stripe
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+//
+// Public constants
+//
+
+// RequestIDHeader and RequestIDMetadataKey are the names under which the
+// correlation ID travels on outgoing HTTP requests and gRPC metadata,
+// respectively. Support engineers can grep for either to line up a user's
+// local logs, telemetry event, and Stripe backend request traces.
+const (
+	RequestIDHeader      = "Stripe-CLI-Request-Id"
+	RequestIDMetadataKey = "stripe-cli-request-id"
+)
+
+//
+// Public functions
+//
+
+// NewRequestID generates a fresh correlation ID for a single CLI
+// invocation.
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// WithRequestID returns a copy of ctx carrying requestID, retrievable with
+// RequestIDFromContext, along with a logger (see RequestLogger) that always
+// logs request_id=requestID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDContextKey{}, requestID)
+	return context.WithValue(ctx, requestLoggerContextKey{}, newRequestLogger(requestID))
+}
+
+// RequestIDFromContext returns the request ID attached to ctx by
+// WithRequestID, or "" if none was attached.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// RequestLogger returns the *log.Logger attached to ctx by WithRequestID.
+// Every line it writes is prefixed with the request ID, so logs from a
+// single invocation can be correlated even when interleaved with other
+// concurrent commands.
+func RequestLogger(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value(requestLoggerContextKey{}).(*log.Logger); ok && logger != nil {
+		return logger
+	}
+
+	return newRequestLogger(RequestIDFromContext(ctx))
+}
+
+// SetHTTPRequestID sets the outgoing request ID header on req from the
+// request ID attached to req's context.
+func SetHTTPRequestID(req *http.Request) {
+	if requestID := RequestIDFromContext(req.Context()); requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
+	}
+}
+
+// UnaryClientInterceptor injects the request ID attached to ctx into the
+// outgoing gRPC metadata of every unary call the CLI makes.
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(outgoingRequestIDContext(ctx), method, req, reply, cc, opts...)
+}
+
+// StreamClientInterceptor injects the request ID attached to ctx into the
+// outgoing gRPC metadata of every streaming call the CLI makes.
+func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingRequestIDContext(ctx), desc, cc, method, opts...)
+}
+
+// UnaryServerInterceptor reads the request ID from incoming gRPC metadata
+// (generating one if the caller didn't send one) and stashes it, along
+// with a request-scoped logger, in the handler's context.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(incomingRequestIDContext(ctx), req)
+}
+
+// StreamServerInterceptor is the streaming counterpart to
+// UnaryServerInterceptor.
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	wrapped := &requestIDServerStream{ServerStream: ss, ctx: incomingRequestIDContext(ss.Context())}
+	return handler(srv, wrapped)
+}
+
+//
+// Private types
+//
+
+type requestIDContextKey struct{}
+type requestLoggerContextKey struct{}
+
+// requestIDServerStream wraps a grpc.ServerStream to override Context so
+// handlers see the request-ID-enriched context.
+type requestIDServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDServerStream) Context() context.Context {
+	return s.ctx
+}
+
+//
+// Private functions
+//
+
+func newRequestLogger(requestID string) *log.Logger {
+	return log.New(os.Stderr, "request_id="+requestID+" ", log.LstdFlags)
+}
+
+// outgoingRequestIDContext attaches the request ID from ctx to ctx's
+// outgoing gRPC metadata, generating one first if ctx doesn't have one yet.
+func outgoingRequestIDContext(ctx context.Context) context.Context {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, requestID)
+}
+
+// incomingRequestIDContext reads the request ID from ctx's incoming gRPC
+// metadata, generating one if the caller didn't send one, and returns a
+// context carrying it and a request-scoped logger.
+func incomingRequestIDContext(ctx context.Context) context.Context {
+	requestID := NewRequestID()
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 && values[0] != "" {
+			requestID = values[0]
+		}
+	}
+
+	return WithRequestID(ctx, requestID)
+}
@@ -0,0 +1,304 @@
+package stripe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-querystring/query"
+	"github.com/google/uuid"
+)
+
+//
+// Public types
+//
+
+// TelemetryClient sends CLI analytics events to a telemetry backend.
+//
+// Implementations must be safe for concurrent use. A client is attached to
+// a context with WithTelemetryClient rather than reached for through a
+// global singleton, so that commands which fan out internally (e.g.
+// webhooks, listen, plugins) can each carry their own per-invocation
+// metadata instead of racing to mutate one shared instance.
+type TelemetryClient interface {
+	// SendEvent sends a single telemetry event describing eventName and
+	// eventValue, annotated with the fields of eventCtx.
+	SendEvent(ctx context.Context, eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) error
+}
+
+//
+// Public functions
+//
+
+// WithTelemetryClient returns a copy of ctx carrying client, retrievable
+// with TelemetryClientFromContext.
+func WithTelemetryClient(ctx context.Context, client TelemetryClient) context.Context {
+	return context.WithValue(ctx, telemetryClientContextKey{}, client)
+}
+
+// TelemetryClientFromContext returns the TelemetryClient attached to ctx by
+// WithTelemetryClient. If none was attached, it returns a
+// NoopTelemetryClient so callers never need to nil-check.
+func TelemetryClientFromContext(ctx context.Context) TelemetryClient {
+	if client, ok := ctx.Value(telemetryClientContextKey{}).(TelemetryClient); ok && client != nil {
+		return client
+	}
+
+	return NoopTelemetryClient{}
+}
+
+// WithEventMetadata returns a copy of ctx carrying eventCtx, retrievable
+// with EventMetadataFromContext. Use this to give a command its own
+// CLIAnalyticsEventContext instead of mutating the process-wide instance
+// returned by GetAnalyticsEventContext.
+func WithEventMetadata(ctx context.Context, eventCtx *CLIAnalyticsEventContext) context.Context {
+	return context.WithValue(ctx, eventMetadataContextKey{}, eventCtx)
+}
+
+// EventMetadataFromContext returns the CLIAnalyticsEventContext attached to
+// ctx by WithEventMetadata. If none was attached, it falls back to the
+// process-wide GetAnalyticsEventContext singleton.
+func EventMetadataFromContext(ctx context.Context) *CLIAnalyticsEventContext {
+	if eventCtx, ok := ctx.Value(eventMetadataContextKey{}).(*CLIAnalyticsEventContext); ok && eventCtx != nil {
+		return eventCtx
+	}
+
+	return GetAnalyticsEventContext()
+}
+
+//
+// NoopTelemetryClient
+//
+
+// NoopTelemetryClient discards every event. It's the default backend used
+// when no client has been attached to a context, and is convenient for
+// tests and for users who've opted out of telemetry entirely.
+type NoopTelemetryClient struct{}
+
+// SendEvent implements TelemetryClient.
+func (NoopTelemetryClient) SendEvent(ctx context.Context, eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) error {
+	return nil
+}
+
+//
+// BufferedTelemetryClient
+//
+
+// BufferedTelemetryEvent is a single event recorded by a
+// BufferedTelemetryClient.
+type BufferedTelemetryEvent struct {
+	EventContext *CLIAnalyticsEventContext
+	EventName    string
+	EventValue   string
+}
+
+// BufferedTelemetryClient records events in memory instead of sending them
+// over the network, so tests can assert on what the CLI would have
+// reported.
+type BufferedTelemetryClient struct {
+	mu     sync.Mutex
+	Events []BufferedTelemetryEvent
+}
+
+// NewBufferedTelemetryClient returns an empty BufferedTelemetryClient.
+func NewBufferedTelemetryClient() *BufferedTelemetryClient {
+	return &BufferedTelemetryClient{}
+}
+
+// SendEvent implements TelemetryClient.
+func (c *BufferedTelemetryClient) SendEvent(ctx context.Context, eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Events = append(c.Events, BufferedTelemetryEvent{
+		EventContext: eventCtx,
+		EventName:    eventName,
+		EventValue:   eventValue,
+	})
+
+	return nil
+}
+
+//
+// StripeTelemetryClient
+//
+
+// StripeTelemetryClient sends events to r.stripe.com, the Stripe Analytics
+// Service endpoint. It's the default backend used by the CLI.
+type StripeTelemetryClient struct {
+	httpClient *http.Client
+}
+
+// NewStripeTelemetryClient returns a StripeTelemetryClient ready to send
+// events.
+func NewStripeTelemetryClient() *StripeTelemetryClient {
+	return &StripeTelemetryClient{httpClient: newTelemetryHTTPClient(false)}
+}
+
+// SendEvent implements TelemetryClient.
+func (c *StripeTelemetryClient) SendEvent(ctx context.Context, eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) error {
+	if telemetryOptedOut(os.Getenv("STRIPE_CLI_TELEMETRY_OPTOUT")) {
+		return nil
+	}
+
+	analyticsURL, err := url.Parse("https://r.stripe.com/0")
+	if err != nil {
+		return err
+	}
+
+	data, _ := query.Values(eventCtx)
+
+	data.Set("client_id", "stripe-cli")
+	data.Set("event_id", uuid.NewString())
+	data.Set("event_name", eventName)
+	data.Set("event_value", eventValue)
+	data.Set("created", fmt.Sprint(time.Now().Unix()))
+
+	req, err := http.NewRequest(http.MethodPost, analyticsURL.String(), strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("origin", "stripe-cli")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+
+	SetHTTPRequestID(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+
+	if isRetryableStatus(resp.StatusCode) {
+		return fmt.Errorf("telemetry backend responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+//
+// OTLPTelemetryClient
+//
+
+// OTLPTelemetryClient sends events as OpenTelemetry log records to an
+// OTLP/HTTP collector, for users who'd rather CLI telemetry flow into their
+// own observability stack than (or in addition to) r.stripe.com.
+type OTLPTelemetryClient struct {
+	httpClient *http.Client
+	endpoint   string
+}
+
+// NewOTLPTelemetryClient returns an OTLPTelemetryClient that POSTs events as
+// OTLP/HTTP log records to endpoint, e.g. "http://localhost:4318/v1/logs".
+func NewOTLPTelemetryClient(endpoint string) *OTLPTelemetryClient {
+	return &OTLPTelemetryClient{
+		httpClient: newTelemetryHTTPClient(false),
+		endpoint:   endpoint,
+	}
+}
+
+// SendEvent implements TelemetryClient.
+func (c *OTLPTelemetryClient) SendEvent(ctx context.Context, eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) error {
+	body, err := json.Marshal(otlpLogsPayload(eventCtx, eventName, eventValue))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+
+	SetHTTPRequestID(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+
+	if isRetryableStatus(resp.StatusCode) {
+		return fmt.Errorf("telemetry collector responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+//
+// Private types
+//
+
+type telemetryClientContextKey struct{}
+type eventMetadataContextKey struct{}
+
+//
+// Private functions
+//
+
+// isRetryableStatus reports whether statusCode indicates a
+// transient failure worth retrying, as opposed to a permanent client error.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// drainAndClose drains body so the underlying connection can be reused and
+// then closes it. It should be deferred immediately after a successful
+// http.Client.Do.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, body)
+	body.Close()
+}
+
+// otlpLogsPayload builds a minimal OTLP/HTTP JSON logs payload with one log
+// record: eventName as the body, and eventValue plus every field of
+// eventCtx as string-valued attributes.
+func otlpLogsPayload(eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) map[string]interface{} {
+	attrs := []map[string]interface{}{
+		{"key": "event.value", "value": map[string]interface{}{"stringValue": eventValue}},
+	}
+
+	values, _ := query.Values(eventCtx)
+	for key := range values {
+		attrs = append(attrs, map[string]interface{}{
+			"key":   key,
+			"value": map[string]interface{}{"stringValue": values.Get(key)},
+		})
+	}
+
+	return map[string]interface{}{
+		"resourceLogs": []map[string]interface{}{
+			{
+				"scopeLogs": []map[string]interface{}{
+					{
+						"logRecords": []map[string]interface{}{
+							{
+								"timeUnixNano": fmt.Sprint(time.Now().UnixNano()),
+								"body":         map[string]interface{}{"stringValue": eventName},
+								"attributes":   attrs,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,195 @@
+package stripe
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/stripe/metrics"
+)
+
+//
+// Public types
+//
+
+// TelemetryQueue batches telemetry events and flushes them to a
+// TelemetryClient in the background, instead of the old fire-and-forget
+// SendEvent that blocked every invocation for 5 seconds. Callers enqueue
+// events with EnqueueEvent; the queue itself decides when to flush based on
+// its batchSize and flushInterval.
+type TelemetryQueue struct {
+	client        TelemetryClient
+	events        chan telemetryQueueEvent
+	done          chan struct{}
+	batchSize     int
+	flushInterval time.Duration
+	shutdownOnce  sync.Once
+}
+
+//
+// Public functions
+//
+
+// StartTelemetry starts a TelemetryQueue backed by the TelemetryClient
+// attached to ctx (see TelemetryClientFromContext) and returns a context
+// carrying it, so that later calls to EnqueueEvent and ShutdownTelemetry
+// can find it. It's called once per invocation, from the root command's
+// Execute.
+func StartTelemetry(ctx context.Context) context.Context {
+	queue := newTelemetryQueue(TelemetryClientFromContext(ctx), telemetryQueueSize, telemetryBatchSize, telemetryFlushInterval)
+
+	go queue.run()
+
+	return context.WithValue(ctx, telemetryQueueContextKey{}, queue)
+}
+
+// newTelemetryQueue builds a TelemetryQueue without starting its background
+// worker, so tests can exercise run/flush with a small batchSize and
+// flushInterval instead of the production defaults.
+func newTelemetryQueue(client TelemetryClient, queueSize, batchSize int, flushInterval time.Duration) *TelemetryQueue {
+	return &TelemetryQueue{
+		client:        client,
+		events:        make(chan telemetryQueueEvent, queueSize),
+		done:          make(chan struct{}),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+}
+
+// ShutdownTelemetry flushes any events still queued on the TelemetryQueue
+// started by StartTelemetry and stops its background worker. It waits at
+// most telemetryShutdownTimeout for in-flight events to be sent, so the CLI
+// never hangs on exit waiting for a slow or unreachable telemetry backend.
+// It's safe to call more than once; only the first call closes the queue.
+func ShutdownTelemetry(ctx context.Context) {
+	queue, ok := ctx.Value(telemetryQueueContextKey{}).(*TelemetryQueue)
+	if !ok || queue == nil {
+		return
+	}
+
+	queue.shutdownOnce.Do(func() {
+		close(queue.events)
+	})
+
+	select {
+	case <-queue.done:
+	case <-time.After(telemetryShutdownTimeout):
+	}
+}
+
+// EnqueueEvent enqueues an event for the TelemetryQueue started by
+// StartTelemetry. If no queue was started (e.g. in tests), the event is
+// sent immediately through the context's TelemetryClient instead.
+func EnqueueEvent(ctx context.Context, eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) {
+	queue, ok := ctx.Value(telemetryQueueContextKey{}).(*TelemetryQueue)
+	if !ok || queue == nil {
+		_ = TelemetryClientFromContext(ctx).SendEvent(ctx, eventCtx, eventName, eventValue)
+		return
+	}
+
+	select {
+	case queue.events <- telemetryQueueEvent{ctx: ctx, eventCtx: eventCtx, eventName: eventName, eventValue: eventValue}:
+	default:
+		// The queue is full; drop the event rather than block the command.
+		metrics.TelemetryEventsDropped.Inc()
+	}
+}
+
+//
+// Private types
+//
+
+type telemetryQueueContextKey struct{}
+
+type telemetryQueueEvent struct {
+	ctx        context.Context
+	eventCtx   *CLIAnalyticsEventContext
+	eventName  string
+	eventValue string
+}
+
+//
+// Private constants
+//
+
+const (
+	telemetryQueueSize       = 100
+	telemetryBatchSize       = 20
+	telemetryFlushInterval   = 10 * time.Second
+	telemetryShutdownTimeout = 800 * time.Millisecond
+	telemetryMaxRetries      = 3
+	telemetryRetryBaseDelay  = 200 * time.Millisecond
+)
+
+//
+// Private functions
+//
+
+// run batches incoming events and flushes them whenever the batch reaches
+// q.batchSize or q.flushInterval elapses, whichever comes first. It returns
+// once the events channel is closed and the final batch has been flushed,
+// signalling completion on q.done.
+func (q *TelemetryQueue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]telemetryQueueEvent, 0, q.batchSize)
+
+	for {
+		select {
+		case event, open := <-q.events:
+			if !open {
+				q.flush(batch)
+				return
+			}
+
+			batch = append(batch, event)
+			if len(batch) >= q.batchSize {
+				q.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			q.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush sends every event in batch through q.client, retrying transient
+// HTTP failures with exponential backoff.
+func (q *TelemetryQueue) flush(batch []telemetryQueueEvent) {
+	for _, event := range batch {
+		q.sendWithRetry(event)
+	}
+}
+
+func (q *TelemetryQueue) sendWithRetry(event telemetryQueueEvent) {
+	var err error
+
+	for attempt := 0; attempt < telemetryMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(telemetryRetryBaseDelay * time.Duration(1<<uint(attempt-1)))
+		}
+
+		if err = q.client.SendEvent(event.ctx, event.eventCtx, event.eventName, event.eventValue); err == nil {
+			metrics.TelemetryEventsSent.Inc()
+			return
+		}
+	}
+
+	metrics.TelemetryEventsDropped.Inc()
+}
+
+// ExitWithTelemetry emits a CommandExited event carrying code, shuts down
+// the TelemetryQueue started by StartTelemetry, and then calls os.Exit.
+// Commands should call this instead of os.Exit directly so the analytics
+// pipeline always sees a terminal outcome for every invocation.
+func ExitWithTelemetry(ctx context.Context, code int) {
+	EnqueueEvent(ctx, EventMetadataFromContext(ctx), "CommandExited", strconv.Itoa(code))
+	ShutdownTelemetry(ctx)
+	os.Exit(code)
+}
@@ -0,0 +1,129 @@
+package stripe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/stripe/stripe-cli/pkg/stripe/metrics"
+)
+
+// waitForEvents polls client.Events until it has at least n entries or
+// timeout elapses, returning the final count.
+func waitForEvents(client *BufferedTelemetryClient, n int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		client.mu.Lock()
+		count := len(client.Events)
+		client.mu.Unlock()
+
+		if count >= n {
+			return count
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return len(client.Events)
+}
+
+func TestTelemetryQueueFlushesOnBatchSize(t *testing.T) {
+	client := NewBufferedTelemetryClient()
+	queue := newTelemetryQueue(client, 10, 3, time.Hour)
+
+	go queue.run()
+	defer close(queue.events)
+
+	for i := 0; i < 3; i++ {
+		queue.events <- telemetryQueueEvent{ctx: context.Background(), eventCtx: &CLIAnalyticsEventContext{}, eventName: "test"}
+	}
+
+	if got := waitForEvents(client, 3, time.Second); got != 3 {
+		t.Fatalf("expected 3 events flushed on batch size, got %d", got)
+	}
+}
+
+func TestTelemetryQueueFlushesOnInterval(t *testing.T) {
+	client := NewBufferedTelemetryClient()
+	queue := newTelemetryQueue(client, 10, 100, 20*time.Millisecond)
+
+	go queue.run()
+	defer close(queue.events)
+
+	queue.events <- telemetryQueueEvent{ctx: context.Background(), eventCtx: &CLIAnalyticsEventContext{}, eventName: "test"}
+
+	if got := waitForEvents(client, 1, time.Second); got != 1 {
+		t.Fatalf("expected 1 event flushed on interval, got %d", got)
+	}
+}
+
+func TestEnqueueEventDropsWhenFull(t *testing.T) {
+	client := NewBufferedTelemetryClient()
+	queue := newTelemetryQueue(client, 1, 100, time.Hour)
+	ctx := context.WithValue(context.Background(), telemetryQueueContextKey{}, queue)
+
+	before := testutil.ToFloat64(metrics.TelemetryEventsDropped)
+
+	EnqueueEvent(ctx, &CLIAnalyticsEventContext{}, "first", "")
+	EnqueueEvent(ctx, &CLIAnalyticsEventContext{}, "second", "")
+
+	after := testutil.ToFloat64(metrics.TelemetryEventsDropped)
+
+	if after-before != 1 {
+		t.Fatalf("expected exactly one dropped event to be counted, got delta %v", after-before)
+	}
+
+	if len(queue.events) != 1 {
+		t.Fatalf("expected the queue to still hold the first event, got %d queued", len(queue.events))
+	}
+}
+
+func TestShutdownTelemetryRespectsTimeout(t *testing.T) {
+	client := &slowTelemetryClient{delay: 5 * time.Second}
+	queue := newTelemetryQueue(client, 10, 1, time.Hour)
+	ctx := context.WithValue(context.Background(), telemetryQueueContextKey{}, queue)
+
+	go queue.run()
+	queue.events <- telemetryQueueEvent{ctx: context.Background(), eventCtx: &CLIAnalyticsEventContext{}, eventName: "test"}
+
+	start := time.Now()
+	ShutdownTelemetry(ctx)
+	elapsed := time.Since(start)
+
+	if elapsed > time.Second {
+		t.Fatalf("ShutdownTelemetry should have returned after telemetryShutdownTimeout, took %s", elapsed)
+	}
+}
+
+func TestShutdownTelemetryIsIdempotent(t *testing.T) {
+	client := NewBufferedTelemetryClient()
+	queue := newTelemetryQueue(client, 10, 1, time.Hour)
+	ctx := context.WithValue(context.Background(), telemetryQueueContextKey{}, queue)
+
+	go queue.run()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("calling ShutdownTelemetry twice panicked: %v", r)
+		}
+	}()
+
+	ShutdownTelemetry(ctx)
+	ShutdownTelemetry(ctx)
+}
+
+// slowTelemetryClient simulates an unreachable telemetry backend.
+type slowTelemetryClient struct {
+	delay time.Duration
+}
+
+func (c *slowTelemetryClient) SendEvent(ctx context.Context, eventCtx *CLIAnalyticsEventContext, eventName string, eventValue string) error {
+	time.Sleep(c.delay)
+	return nil
+}